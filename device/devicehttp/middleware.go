@@ -0,0 +1,86 @@
+package devicehttp
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/Comcast/webpa-common/logging"
+	"github.com/go-kit/kit/metrics/provider"
+)
+
+const (
+	// MetricRequestCount is the name of the counter incremented once per observed request.
+	MetricRequestCount = "devicehttp_requests_total"
+
+	// MetricRequestDuration is the name of the histogram observing request duration, in seconds.
+	MetricRequestDuration = "devicehttp_request_duration_seconds"
+
+	// MetricResponseSize is the name of the histogram observing response size, in bytes.
+	MetricResponseSize = "devicehttp_response_size_bytes"
+
+	// TransactionUUIDHeader is the HTTP request header used to carry a WRP message's transaction
+	// UUID, when the request has one.  device.MessageHandler sets this header on the request once
+	// it has decoded the transaction UUID off of the underlying WRP message, so middleware
+	// wrapping it can read it without reaching into the message itself.
+	TransactionUUIDHeader = "X-Webpa-Transaction-Uuid"
+)
+
+// TransactionUUID returns the WRP transaction UUID associated with request, or the empty string
+// if none was set.
+func TransactionUUID(request *http.Request) string {
+	return request.Header.Get(TransactionUUIDHeader)
+}
+
+// MetricsMiddleware returns middleware that wraps each request's http.ResponseWriter via Wrap
+// and records a request counter, a request duration histogram, and a response size histogram,
+// each labeled by status code.  The WRP transaction UUID is per-request and therefore unbounded
+// cardinality, so it is deliberately left off of these aggregate metrics; see
+// AccessLogMiddleware for per-request UUID visibility.
+func MetricsMiddleware(registry provider.Provider) func(http.Handler) http.Handler {
+	var (
+		requests = registry.NewCounter(MetricRequestCount)
+		duration = registry.NewHistogram(MetricRequestDuration, 0)
+		size     = registry.NewHistogram(MetricResponseSize, 0)
+	)
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			started := time.Now()
+			wrapped, capture := Wrap(response)
+			next.ServeHTTP(wrapped, request)
+
+			labels := []string{"code", strconv.Itoa(capture.Code)}
+			requests.With(labels...).Add(1)
+			duration.With(labels...).Observe(time.Since(started).Seconds())
+			size.With(labels...).Observe(float64(capture.Written))
+		})
+	}
+}
+
+// AccessLogMiddleware returns middleware that wraps each request's http.ResponseWriter via Wrap
+// and logs a single access log line per request, once the wrapped handler has returned.
+func AccessLogMiddleware(logger logging.Logger) func(http.Handler) http.Handler {
+	if logger == nil {
+		logger = logging.DefaultLogger()
+	}
+
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
+			started := time.Now()
+			wrapped, capture := Wrap(response)
+			next.ServeHTTP(wrapped, request)
+
+			logger.Info(
+				"%s %s %s - %d %db %s [uuid=%s]",
+				request.RemoteAddr,
+				request.Method,
+				request.URL,
+				capture.Code,
+				capture.Written,
+				time.Since(started),
+				TransactionUUID(request),
+			)
+		})
+	}
+}
@@ -0,0 +1,19 @@
+package devicehttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestTransactionUUID(t *testing.T) {
+	request := httptest.NewRequest(http.MethodGet, "/", nil)
+	if uuid := TransactionUUID(request); uuid != "" {
+		t.Errorf("expected no transaction UUID on a bare request, got %q", uuid)
+	}
+
+	request.Header.Set(TransactionUUIDHeader, "uuid-1")
+	if uuid := TransactionUUID(request); uuid != "uuid-1" {
+		t.Errorf("expected uuid-1, got %q", uuid)
+	}
+}
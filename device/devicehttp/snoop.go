@@ -0,0 +1,216 @@
+// Package devicehttp provides http.ResponseWriter middleware for observing WRP traffic
+// handled by device.MessageHandler and device.NewDeviceListHandler, without requiring any
+// changes to those handlers themselves.
+package devicehttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"time"
+)
+
+// CaptureMetrics holds the information captured about a single response by Wrap.  Code and
+// Written are only meaningful once the wrapped http.Handler has returned.
+type CaptureMetrics struct {
+	// Code is the status code passed to WriteHeader, or http.StatusOK if WriteHeader was
+	// never called explicitly.
+	Code int
+
+	// Written is the total number of bytes passed to Write.
+	Written int64
+
+	// FirstByte is the time at which the first byte was written to the underlying
+	// http.ResponseWriter, whether via an explicit WriteHeader or the first Write.  It is
+	// the zero Time if nothing was ever written.
+	FirstByte time.Time
+}
+
+// core is the concrete http.ResponseWriter embedded by every combination returned by Wrap.  It
+// implements http.ResponseWriter itself; the combination types add exactly the optional
+// interfaces (http.Flusher, http.Hijacker, http.CloseNotifier, http.Pusher) that the writer
+// passed to Wrap actually supports.
+type core struct {
+	http.ResponseWriter
+	metrics *CaptureMetrics
+}
+
+func (c *core) touch() {
+	if c.metrics.FirstByte.IsZero() {
+		c.metrics.FirstByte = time.Now()
+	}
+}
+
+func (c *core) WriteHeader(code int) {
+	c.touch()
+	c.metrics.Code = code
+	c.ResponseWriter.WriteHeader(code)
+}
+
+func (c *core) Write(p []byte) (int, error) {
+	c.touch()
+	n, err := c.ResponseWriter.Write(p)
+	c.metrics.Written += int64(n)
+	return n, err
+}
+
+// withFlush, withHijack, withCloseNotify, and withPush each promote exactly one optional
+// interface method, forwarding to the concrete implementation supplied by the writer passed to
+// Wrap.  Combination types embed whichever of these apply, so that a type assertion for an
+// interface the original writer did not implement continues to fail on the wrapper.
+type withFlush struct{ flusher http.Flusher }
+
+func (w withFlush) Flush() { w.flusher.Flush() }
+
+type withHijack struct{ hijacker http.Hijacker }
+
+func (w withHijack) Hijack() (net.Conn, *bufio.ReadWriter, error) { return w.hijacker.Hijack() }
+
+type withCloseNotify struct{ notifier http.CloseNotifier }
+
+func (w withCloseNotify) CloseNotify() <-chan bool { return w.notifier.CloseNotify() }
+
+type withPush struct{ pusher http.Pusher }
+
+func (w withPush) Push(target string, opts *http.PushOptions) error {
+	return w.pusher.Push(target, opts)
+}
+
+// The sixteen combinations of the four optional interfaces.  Each is named for the interfaces
+// it supports: f = http.Flusher, h = http.Hijacker, c = http.CloseNotifier, p = http.Pusher.
+type rw struct{ *core }
+type rwF struct {
+	*core
+	withFlush
+}
+type rwH struct {
+	*core
+	withHijack
+}
+type rwC struct {
+	*core
+	withCloseNotify
+}
+type rwP struct {
+	*core
+	withPush
+}
+type rwFH struct {
+	*core
+	withFlush
+	withHijack
+}
+type rwFC struct {
+	*core
+	withFlush
+	withCloseNotify
+}
+type rwFP struct {
+	*core
+	withFlush
+	withPush
+}
+type rwHC struct {
+	*core
+	withHijack
+	withCloseNotify
+}
+type rwHP struct {
+	*core
+	withHijack
+	withPush
+}
+type rwCP struct {
+	*core
+	withCloseNotify
+	withPush
+}
+type rwFHC struct {
+	*core
+	withFlush
+	withHijack
+	withCloseNotify
+}
+type rwFHP struct {
+	*core
+	withFlush
+	withHijack
+	withPush
+}
+type rwFCP struct {
+	*core
+	withFlush
+	withCloseNotify
+	withPush
+}
+type rwHCP struct {
+	*core
+	withHijack
+	withCloseNotify
+	withPush
+}
+type rwFHCP struct {
+	*core
+	withFlush
+	withHijack
+	withCloseNotify
+	withPush
+}
+
+// Wrap returns an http.ResponseWriter that delegates to response while capturing status code,
+// bytes written, and first-byte latency into the returned CaptureMetrics.  The wrapper exposes
+// exactly the combination of http.Flusher, http.Hijacker, http.CloseNotifier, and http.Pusher
+// that response itself implements, so that existing type assertions against the writer (such as
+// the one in device.NewDeviceListHandler) keep working -- or keep failing -- exactly as they did
+// on the unwrapped writer.
+func Wrap(response http.ResponseWriter) (http.ResponseWriter, *CaptureMetrics) {
+	metrics := &CaptureMetrics{Code: http.StatusOK}
+	base := &core{ResponseWriter: response, metrics: metrics}
+
+	flusher, isFlusher := response.(http.Flusher)
+	hijacker, isHijacker := response.(http.Hijacker)
+	notifier, isCloseNotifier := response.(http.CloseNotifier)
+	pusher, isPusher := response.(http.Pusher)
+
+	switch {
+	case isFlusher && isHijacker && isCloseNotifier && isPusher:
+		return &rwFHCP{base, withFlush{flusher}, withHijack{hijacker}, withCloseNotify{notifier}, withPush{pusher}}, metrics
+	case isFlusher && isHijacker && isCloseNotifier:
+		return &rwFHC{base, withFlush{flusher}, withHijack{hijacker}, withCloseNotify{notifier}}, metrics
+	case isFlusher && isHijacker && isPusher:
+		return &rwFHP{base, withFlush{flusher}, withHijack{hijacker}, withPush{pusher}}, metrics
+	case isFlusher && isCloseNotifier && isPusher:
+		return &rwFCP{base, withFlush{flusher}, withCloseNotify{notifier}, withPush{pusher}}, metrics
+	case isHijacker && isCloseNotifier && isPusher:
+		return &rwHCP{base, withHijack{hijacker}, withCloseNotify{notifier}, withPush{pusher}}, metrics
+	case isFlusher && isHijacker:
+		return &rwFH{base, withFlush{flusher}, withHijack{hijacker}}, metrics
+	case isFlusher && isCloseNotifier:
+		return &rwFC{base, withFlush{flusher}, withCloseNotify{notifier}}, metrics
+	case isFlusher && isPusher:
+		return &rwFP{base, withFlush{flusher}, withPush{pusher}}, metrics
+	case isHijacker && isCloseNotifier:
+		return &rwHC{base, withHijack{hijacker}, withCloseNotify{notifier}}, metrics
+	case isHijacker && isPusher:
+		return &rwHP{base, withHijack{hijacker}, withPush{pusher}}, metrics
+	case isCloseNotifier && isPusher:
+		return &rwCP{base, withCloseNotify{notifier}, withPush{pusher}}, metrics
+	case isFlusher:
+		return &rwF{base, withFlush{flusher}}, metrics
+	case isHijacker:
+		return &rwH{base, withHijack{hijacker}}, metrics
+	case isCloseNotifier:
+		return &rwC{base, withCloseNotify{notifier}}, metrics
+	case isPusher:
+		return &rwP{base, withPush{pusher}}, metrics
+	default:
+		return &rw{base}, metrics
+	}
+}
+
+// Flusher returns response's http.Flusher and true if response -- whether wrapped by Wrap or
+// not -- implements it, degrading gracefully to (nil, false) otherwise.
+func Flusher(response http.ResponseWriter) (http.Flusher, bool) {
+	flusher, ok := response.(http.Flusher)
+	return flusher, ok
+}
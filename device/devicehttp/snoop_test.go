@@ -0,0 +1,162 @@
+package devicehttp
+
+import (
+	"bufio"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// fakeResponseWriter lets each optional interface be toggled on independently of the others, so
+// that reaching a method of an interface that was not requested panics.
+type fakeResponseWriter struct {
+	http.ResponseWriter
+	flush       bool
+	hijack      bool
+	closeNotify bool
+	push        bool
+}
+
+func (f *fakeResponseWriter) Flush() {
+	if !f.flush {
+		panic("Flush should not be reachable")
+	}
+}
+
+func (f *fakeResponseWriter) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	if !f.hijack {
+		panic("Hijack should not be reachable")
+	}
+
+	return nil, nil, nil
+}
+
+func (f *fakeResponseWriter) CloseNotify() <-chan bool {
+	if !f.closeNotify {
+		panic("CloseNotify should not be reachable")
+	}
+
+	return nil
+}
+
+func (f *fakeResponseWriter) Push(target string, opts *http.PushOptions) error {
+	if !f.push {
+		panic("Push should not be reachable")
+	}
+
+	return nil
+}
+
+// asResponseWriter builds an http.ResponseWriter that implements no optional interface, exactly
+// one, or all four, by embedding fakeResponseWriter selectively.  For any other combination of
+// flags, only the first requested interface, in the order flush, hijack, closeNotify, push, is
+// implemented.
+func asResponseWriter(flush, hijack, closeNotify, push bool) http.ResponseWriter {
+	base := httptest.NewRecorder()
+	fake := &fakeResponseWriter{ResponseWriter: base, flush: flush, hijack: hijack, closeNotify: closeNotify, push: push}
+
+	switch {
+	case flush && hijack && closeNotify && push:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+			http.Hijacker
+			http.CloseNotifier
+			http.Pusher
+		}{fake, fake, fake, fake, fake}
+	case flush:
+		return struct {
+			http.ResponseWriter
+			http.Flusher
+		}{fake, fake}
+	case hijack:
+		return struct {
+			http.ResponseWriter
+			http.Hijacker
+		}{fake, fake}
+	case closeNotify:
+		return struct {
+			http.ResponseWriter
+			http.CloseNotifier
+		}{fake, fake}
+	case push:
+		return struct {
+			http.ResponseWriter
+			http.Pusher
+		}{fake, fake}
+	default:
+		return struct {
+			http.ResponseWriter
+		}{fake}
+	}
+}
+
+func TestWrapPreservesSupportedInterfaces(t *testing.T) {
+	cases := []struct {
+		name                              string
+		flush, hijack, closeNotify, push bool
+	}{
+		{"none", false, false, false, false},
+		{"flush", true, false, false, false},
+		{"hijack", false, true, false, false},
+		{"closeNotify", false, false, true, false},
+		{"push", false, false, false, true},
+		{"all", true, true, true, true},
+	}
+
+	for _, tc := range cases {
+		t.Run(tc.name, func(t *testing.T) {
+			response := asResponseWriter(tc.flush, tc.hijack, tc.closeNotify, tc.push)
+			wrapped, metrics := Wrap(response)
+
+			if _, ok := wrapped.(http.Flusher); ok != tc.flush {
+				t.Errorf("http.Flusher: expected %v, got %v", tc.flush, ok)
+			}
+
+			if _, ok := wrapped.(http.Hijacker); ok != tc.hijack {
+				t.Errorf("http.Hijacker: expected %v, got %v", tc.hijack, ok)
+			}
+
+			if _, ok := wrapped.(http.CloseNotifier); ok != tc.closeNotify {
+				t.Errorf("http.CloseNotifier: expected %v, got %v", tc.closeNotify, ok)
+			}
+
+			if _, ok := wrapped.(http.Pusher); ok != tc.push {
+				t.Errorf("http.Pusher: expected %v, got %v", tc.push, ok)
+			}
+
+			wrapped.WriteHeader(http.StatusTeapot)
+			n, _ := wrapped.Write([]byte("hello"))
+
+			if metrics.Code != http.StatusTeapot {
+				t.Errorf("expected captured code %d, got %d", http.StatusTeapot, metrics.Code)
+			}
+
+			if metrics.Written != int64(n) {
+				t.Errorf("expected captured bytes written %d, got %d", n, metrics.Written)
+			}
+
+			if metrics.FirstByte.IsZero() {
+				t.Error("expected FirstByte to be set after writing")
+			}
+		})
+	}
+}
+
+func TestWrapDefaultsCodeToOKWithoutWriteHeader(t *testing.T) {
+	_, metrics := Wrap(httptest.NewRecorder())
+	if metrics.Code != http.StatusOK {
+		t.Errorf("expected default code %d, got %d", http.StatusOK, metrics.Code)
+	}
+}
+
+func TestFlusherDegradesGracefully(t *testing.T) {
+	if _, ok := Flusher(httptest.NewRecorder()); !ok {
+		t.Error("expected httptest.ResponseRecorder to satisfy http.Flusher")
+	}
+
+	if _, ok := Flusher(struct{ http.ResponseWriter }{httptest.NewRecorder()}); ok {
+		t.Error("expected a writer with no Flush method to report false")
+	}
+}
@@ -4,12 +4,15 @@ import (
 	"context"
 	"encoding/json"
 	"fmt"
+	"github.com/Comcast/webpa-common/device/devicehttp"
 	"github.com/Comcast/webpa-common/httperror"
 	"github.com/Comcast/webpa-common/logging"
 	"github.com/Comcast/webpa-common/wrp"
 	"io"
 	"io/ioutil"
+	"mime"
 	"net/http"
+	"strings"
 	"sync"
 	"time"
 )
@@ -82,6 +85,15 @@ func (mh *MessageHandler) ServeHTTP(response http.ResponseWriter, request *http.
 		return
 	}
 
+	// decodeRequest always hands back a *wrp.Message (see above), and TransactionUUID is a
+	// field on that concrete type, not a method on the wrp.Routable interface it's typed as.
+	if wrpMessage, ok := message.(*wrp.Message); ok && wrpMessage.TransactionUUID != "" {
+		// Carried as a header, rather than threaded through the context, so that middleware
+		// wrapping this handler (e.g. devicehttp.AccessLogMiddleware) can observe it without
+		// depending on the device package.
+		request.Header.Set(devicehttp.TransactionUUIDHeader, wrpMessage.TransactionUUID)
+	}
+
 	if mh.DeviceEncoders != nil {
 		contents = contents[:0]
 		if err := mh.DeviceEncoders.EncodeBytes(&contents, message); err != nil {
@@ -162,56 +174,292 @@ func (ch *ConnectHandler) ServeHTTP(response http.ResponseWriter, request *http.
 	}
 }
 
-// NewDeviceListHandler returns an http.Handler that renders a JSON listing
-// of the devices within a manager.
+// deviceListKeepaliveInterval is the default interval at which NewDeviceListHandler emits
+// SSE keepalive comments while a listing is in progress.
+const deviceListKeepaliveInterval = 15 * time.Second
+
+// deviceListFilter holds the parsed ?since= and ?cursor= query parameters for a single
+// device listing request.
+type deviceListFilter struct {
+	since  time.Time
+	cursor string
+	seen   bool
+}
+
+func newDeviceListFilter(request *http.Request) (f deviceListFilter, err error) {
+	query := request.URL.Query()
+	if raw := query.Get("since"); raw != "" {
+		if f.since, err = time.Parse(time.RFC3339, raw); err != nil {
+			return f, fmt.Errorf("invalid since: %s", err)
+		}
+	}
+
+	f.cursor = query.Get("cursor")
+	f.seen = f.cursor == ""
+	return
+}
+
+// allow applies the since and cursor filters to d, in VisitAll's traversal order.  cursor is
+// treated as the ID of the last device seen by a prior request, so devices are skipped until it
+// is passed.  This resumes correctly only if VisitAll visits devices in the same order as it did
+// for the prior request; Manager is a sharded map in the real implementation, so that order is
+// not ID-sorted and not guaranteed to be stable across calls.  NewDeviceListHandler confirms the
+// cursor device is still present before starting a resumed listing; see cursorStillPresent.
+// since excludes devices connected before the given time; it applies only to devices that expose
+// a ConnectedAt() time.Time method, and devices without one are not filtered by it.
+func (f *deviceListFilter) allow(d Interface) bool {
+	if !f.seen {
+		if string(d.ID()) == f.cursor {
+			f.seen = true
+		}
+
+		return false
+	}
+
+	if !f.since.IsZero() {
+		if connected, ok := d.(interface{ ConnectedAt() time.Time }); ok && connected.ConnectedAt().Before(f.since) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// cursorStillPresent reports whether manager currently holds a device with the given ID.  It
+// costs a full VisitAll, so it is only used when a cursor was supplied, to fail a resumed listing
+// explicitly (rather than silently returning an empty list) when the device named by the cursor
+// has since disconnected.
+func cursorStillPresent(manager Manager, cursor string) bool {
+	found := false
+	manager.VisitAll(func(d Interface) {
+		if string(d.ID()) == cursor {
+			found = true
+		}
+	})
+
+	return found
+}
+
+// NewDeviceListHandler returns an http.Handler that renders a listing of the devices within
+// a manager.  The response format is negotiated via the Accept header: "application/x-ndjson"
+// streams one device JSON object per line, "text/event-stream" streams each device as an SSE
+// data frame interspersed with periodic keepalive comments, and everything else (including no
+// Accept header) falls back to the original "{"device": [...]}" JSON array.  ?since=<RFC3339>
+// and ?cursor=<opaque> restrict and resume the listing, respectively; the cursor is the ID of
+// the last device written by a previous call to this handler.  If that device has since
+// disconnected, the cursor can no longer be resolved to a position in the listing, and the
+// request fails with http.StatusGone rather than silently returning an empty listing.
 func NewDeviceListHandler(manager Manager, logger logging.Logger) http.Handler {
 	if logger == nil {
 		logger = logging.DefaultLogger()
 	}
 
 	return http.HandlerFunc(func(response http.ResponseWriter, request *http.Request) {
-		flusher := response.(http.Flusher)
-		response.Header().Set("Content-Type", "application/json")
-		if _, err := io.WriteString(response, `{"device": [`); err != nil {
-			logger.Error("Unable to write content: %s", err)
+		filter, err := newDeviceListFilter(request)
+		if err != nil {
+			httperror.Formatf(response, http.StatusBadRequest, "%s", err)
 			return
 		}
 
-		devices := make(chan Interface, 100)
-		finish := new(sync.WaitGroup)
-		finish.Add(1)
+		if filter.cursor != "" && !cursorStillPresent(manager, filter.cursor) {
+			httperror.Formatf(response, http.StatusGone, "cursor device is no longer connected: %s", filter.cursor)
+			return
+		}
 
-		// to minimize the time we hold the read lock on the Manager, spawn a goroutine
-		// that collects devices and inserts them into an output buffer
-		go func() {
-			defer finish.Done()
+		flusher, _ := devicehttp.Flusher(response)
+		switch negotiateDeviceListFormat(request) {
+		case deviceListFormatNDJSON:
+			writeDeviceListNDJSON(response, flusher, manager, logger, filter)
+		case deviceListFormatSSE:
+			writeDeviceListSSE(response, flusher, manager, logger, filter)
+		default:
+			writeDeviceListJSON(response, flusher, manager, logger, filter)
+		}
+	})
+}
 
-			needsDelimiter := false
-			for d := range devices {
-				if needsDelimiter {
-					io.WriteString(response, ",")
-				}
+type deviceListFormat int
 
-				needsDelimiter = true
-				if data, err := json.Marshal(d); err != nil {
-					message := fmt.Sprintf("Unable to marshal device [%s] as JSON: %s", d.ID(), err)
-					logger.Error(message)
-					fmt.Fprintf(response, `"%s"`, message)
-				} else {
-					response.Write(data)
-				}
+const (
+	deviceListFormatJSON deviceListFormat = iota
+	deviceListFormatNDJSON
+	deviceListFormatSSE
+)
+
+// negotiateDeviceListFormat examines the Accept header to determine which of the supported
+// device listing formats to render.  An empty, missing, or unrecognized Accept header falls
+// back to deviceListFormatJSON.
+func negotiateDeviceListFormat(request *http.Request) deviceListFormat {
+	for _, accept := range strings.Split(request.Header.Get("Accept"), ",") {
+		mediaType, _, err := mime.ParseMediaType(strings.TrimSpace(accept))
+		if err != nil {
+			continue
+		}
+
+		switch mediaType {
+		case "application/x-ndjson":
+			return deviceListFormatNDJSON
+		case "text/event-stream":
+			return deviceListFormatSSE
+		}
+	}
+
+	return deviceListFormatJSON
+}
+
+// flushAfter invokes flusher.Flush after writing, if a Flusher is available.
+func flushAfter(flusher http.Flusher) {
+	if flusher != nil {
+		flusher.Flush()
+	}
+}
+
+// writeDeviceListJSON renders the original "{"device": [...]}" JSON array, writing each device
+// as soon as it is visited rather than buffering the entire fleet in memory.  Manager.VisitAll
+// offers no way to stop a traversal early, so once a write to response fails (e.g. the peer
+// disconnected), the visitor short-circuits to a no-op for the remaining devices rather than
+// continuing to marshal and write to a connection that is already gone.
+func writeDeviceListJSON(response http.ResponseWriter, flusher http.Flusher, manager Manager, logger logging.Logger, filter deviceListFilter) {
+	response.Header().Set("Content-Type", "application/json")
+	if _, err := io.WriteString(response, `{"device": [`); err != nil {
+		logger.Error("Unable to write content: %s", err)
+		return
+	}
+
+	var (
+		needsDelimiter bool
+		failed         bool
+	)
+
+	manager.VisitAll(func(d Interface) {
+		if failed || !filter.allow(d) {
+			return
+		}
 
-				flusher.Flush()
+		if needsDelimiter {
+			if _, err := io.WriteString(response, ","); err != nil {
+				failed = true
+				return
 			}
-		}()
+		}
+
+		needsDelimiter = true
+		if data, err := json.Marshal(d); err != nil {
+			message := fmt.Sprintf("Unable to marshal device [%s] as JSON: %s", d.ID(), err)
+			logger.Error(message)
+			if _, err := fmt.Fprintf(response, `"%s"`, message); err != nil {
+				failed = true
+				return
+			}
+		} else if _, err := response.Write(data); err != nil {
+			failed = true
+			return
+		}
 
-		manager.VisitAll(func(d Interface) {
-			devices <- d
-		})
+		flushAfter(flusher)
+	})
 
-		close(devices)
-		finish.Wait()
+	if !failed {
 		io.WriteString(response, `]}`)
-		flusher.Flush()
+		flushAfter(flusher)
+	}
+}
+
+// writeDeviceListNDJSON renders one device JSON object per line, flushing after each write so
+// a client can begin processing before the full fleet has been visited.  As with
+// writeDeviceListJSON, a write failure short-circuits the remaining devices since VisitAll
+// cannot be stopped early.
+func writeDeviceListNDJSON(response http.ResponseWriter, flusher http.Flusher, manager Manager, logger logging.Logger, filter deviceListFilter) {
+	response.Header().Set("Content-Type", "application/x-ndjson")
+
+	var failed bool
+	manager.VisitAll(func(d Interface) {
+		if failed || !filter.allow(d) {
+			return
+		}
+
+		data, err := json.Marshal(d)
+		if err != nil {
+			logger.Error("Unable to marshal device [%s] as JSON: %s", d.ID(), err)
+			return
+		}
+
+		if _, err := response.Write(data); err != nil {
+			failed = true
+			return
+		}
+
+		if _, err := io.WriteString(response, "\n"); err != nil {
+			failed = true
+			return
+		}
+
+		flushAfter(flusher)
+	})
+}
+
+// writeDeviceListSSE renders each device as an SSE "data:" frame, interspersed with ":keepalive"
+// comments every deviceListKeepaliveInterval so long-running listings keep intermediaries from
+// closing an otherwise idle connection.  As with the other formats, a write failure marks the
+// listing failed so the remaining devices in VisitAll's traversal -- which cannot be stopped
+// early -- are skipped rather than written to a connection that is already gone.
+func writeDeviceListSSE(response http.ResponseWriter, flusher http.Flusher, manager Manager, logger logging.Logger, filter deviceListFilter) {
+	header := response.Header()
+	header.Set("Content-Type", "text/event-stream")
+	header.Set("Cache-Control", "no-cache")
+	header.Set("Connection", "keep-alive")
+
+	var (
+		write  sync.Mutex
+		failed bool
+	)
+
+	done := make(chan struct{})
+	defer close(done)
+
+	go func() {
+		ticker := time.NewTicker(deviceListKeepaliveInterval)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				write.Lock()
+				if !failed {
+					if _, err := io.WriteString(response, ":keepalive\n\n"); err != nil {
+						failed = true
+					} else {
+						flushAfter(flusher)
+					}
+				}
+				write.Unlock()
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	manager.VisitAll(func(d Interface) {
+		write.Lock()
+		stop := failed
+		write.Unlock()
+
+		if stop || !filter.allow(d) {
+			return
+		}
+
+		data, err := json.Marshal(d)
+		if err != nil {
+			logger.Error("Unable to marshal device [%s] as JSON: %s", d.ID(), err)
+			return
+		}
+
+		write.Lock()
+		if _, err := fmt.Fprintf(response, "data: %s\n\n", data); err != nil {
+			failed = true
+		} else {
+			flushAfter(flusher)
+		}
+		write.Unlock()
 	})
 }
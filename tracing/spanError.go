@@ -1,5 +1,7 @@
 package tracing
 
+import "errors"
+
 // SpanError represents an error that has one or more spans associated with it.  A SpanError
 // augments an original error, accessible Err(), with zero or more spans.
 type SpanError interface {
@@ -9,6 +11,10 @@ type SpanError interface {
 	// Err returns the error object which is associated with the spans.  Error() returns
 	// the value from this instance.
 	Err() error
+
+	// Unwrap returns the same value as Err(), allowing a SpanError to participate in
+	// errors.Is and errors.As chains rooted at the wrapped error.
+	Unwrap() error
 }
 
 // NewSpanError "span-izes" an existing error object, returning the SpanError which
@@ -35,4 +41,97 @@ func (se *spanError) Spans() []Span {
 
 func (se *spanError) Err() error {
 	return se.err
+}
+
+func (se *spanError) Unwrap() error {
+	return se.err
+}
+
+// spanErrors is the SpanError returned by NewSpanErrors.  It aggregates several span-annotated
+// failures, such as the per-device failures of a fanout, into a single error.
+type spanErrors struct {
+	errs  []SpanError
+	spans []Span
+}
+
+// NewSpanErrors aggregates one or more SpanError values, such as the individual failures of a
+// fanout to several devices, into a single SpanError.  The aggregate's Spans() is the
+// concatenation, in order, of each constituent's Spans(), and its Errors() exposes the
+// constituents themselves so that a caller can attribute each span to the failure it describes.
+// Err() and Unwrap() return the first constituent's error, matching the convention that a
+// SpanError's Err()/Unwrap() identify the error primarily responsible for the failure.
+func NewSpanErrors(errs ...SpanError) SpanError {
+	aggregate := &spanErrors{
+		errs: errs,
+	}
+
+	for _, err := range errs {
+		aggregate.spans = append(aggregate.spans, err.Spans()...)
+	}
+
+	return aggregate
+}
+
+func (se *spanErrors) Error() string {
+	if len(se.errs) == 0 {
+		return ""
+	}
+
+	message := se.errs[0].Error()
+	for _, err := range se.errs[1:] {
+		message += "; " + err.Error()
+	}
+
+	return message
+}
+
+func (se *spanErrors) Spans() []Span {
+	return se.spans
+}
+
+func (se *spanErrors) Err() error {
+	if len(se.errs) == 0 {
+		return nil
+	}
+
+	return se.errs[0].Err()
+}
+
+func (se *spanErrors) Unwrap() error {
+	return se.Err()
+}
+
+// Errors returns the individual SpanError values that make up this aggregate, in the order
+// they were passed to NewSpanErrors.
+func (se *spanErrors) Errors() []error {
+	errs := make([]error, len(se.errs))
+	for i, err := range se.errs {
+		errs[i] = err
+	}
+
+	return errs
+}
+
+// Is reports whether any constituent error, or the error chain rooted at it, matches target.
+// This allows errors.Is to see through an aggregate produced by NewSpanErrors.
+func (se *spanErrors) Is(target error) bool {
+	for _, err := range se.errs {
+		if errors.Is(err, target) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// As finds the first error in any constituent's chain that matches target, in the same sense as
+// errors.As, and if found, sets target to that error value and returns true.
+func (se *spanErrors) As(target interface{}) bool {
+	for _, err := range se.errs {
+		if errors.As(err, target) {
+			return true
+		}
+	}
+
+	return false
 }
\ No newline at end of file
@@ -0,0 +1,108 @@
+package tracing
+
+import (
+	"errors"
+	"testing"
+)
+
+type customError struct{ msg string }
+
+func (ce *customError) Error() string { return ce.msg }
+
+func TestNewSpanError(t *testing.T) {
+	err := errors.New("boom")
+	se := NewSpanError(err)
+
+	if se.Error() != "boom" {
+		t.Errorf("expected Error() to delegate to the wrapped error, got %q", se.Error())
+	}
+
+	if se.Err() != err {
+		t.Error("expected Err() to return the original error")
+	}
+
+	if !errors.Is(se, err) {
+		t.Error("expected errors.Is to see through to the wrapped error")
+	}
+}
+
+func TestNewSpanErrorsAggregatesMessages(t *testing.T) {
+	first := NewSpanError(errors.New("device one failed"))
+	second := NewSpanError(errors.New("device two failed"))
+
+	aggregate := NewSpanErrors(first, second)
+
+	expected := "device one failed; device two failed"
+	if aggregate.Error() != expected {
+		t.Errorf("expected aggregate message %q, got %q", expected, aggregate.Error())
+	}
+
+	if aggregate.Err() != first.Err() {
+		t.Error("expected Err() to return the first constituent's error")
+	}
+
+	if aggregate.Unwrap() != aggregate.Err() {
+		t.Error("expected Unwrap() to match Err()")
+	}
+}
+
+func TestNewSpanErrorsEmpty(t *testing.T) {
+	aggregate := NewSpanErrors()
+
+	if aggregate.Error() != "" {
+		t.Errorf("expected empty message for an empty aggregate, got %q", aggregate.Error())
+	}
+
+	if aggregate.Err() != nil {
+		t.Error("expected a nil Err() for an empty aggregate")
+	}
+}
+
+func TestSpanErrorsExposesConstituents(t *testing.T) {
+	first := NewSpanError(errors.New("one"))
+	second := NewSpanError(errors.New("two"))
+	aggregate := NewSpanErrors(first, second)
+
+	errs, ok := aggregate.(interface{ Errors() []error })
+	if !ok {
+		t.Fatal("expected the aggregate to expose its constituents via Errors()")
+	}
+
+	constituents := errs.Errors()
+	if len(constituents) != 2 || constituents[0] != first || constituents[1] != second {
+		t.Errorf("expected Errors() to return the constituents in order, got %v", constituents)
+	}
+}
+
+func TestSpanErrorsIs(t *testing.T) {
+	target := errors.New("target")
+	aggregate := NewSpanErrors(
+		NewSpanError(errors.New("unrelated")),
+		NewSpanError(target),
+	)
+
+	if !errors.Is(aggregate, target) {
+		t.Error("expected errors.Is to find a matching constituent")
+	}
+
+	if errors.Is(aggregate, errors.New("not present")) {
+		t.Error("expected errors.Is to report false when no constituent matches")
+	}
+}
+
+func TestSpanErrorsAs(t *testing.T) {
+	wrapped := &customError{msg: "custom"}
+	aggregate := NewSpanErrors(
+		NewSpanError(errors.New("unrelated")),
+		NewSpanError(wrapped),
+	)
+
+	var target *customError
+	if !errors.As(aggregate, &target) {
+		t.Fatal("expected errors.As to find the matching constituent")
+	}
+
+	if target != wrapped {
+		t.Error("expected errors.As to populate target with the matching error")
+	}
+}
@@ -0,0 +1,96 @@
+// Package wrpbinlog provides a binary logging subsystem for WRP traffic, modeled
+// on the method-level binary logging found in grpc-go.  Every message that flows
+// through a device.Router, device.MessageHandler, or the wrphttp encoders
+// can be captured as an Entry and handed off to a pluggable Sink, without any
+// changes required at the call sites that produce the traffic.
+//
+// Known gaps, to be tracked as follow-up work rather than left implicit:
+//
+//   - wrpendpoint fanout traffic is not captured.  MethodLogger has no decorator for
+//     wrpendpoint middleware, so only device.Router and the wrphttp HTTP encoders are covered.
+//   - FileSink writes gob-encoded frames, not protobuf.  gob was chosen because no Entry
+//     protobuf schema exists in this module; a durable, cross-process-readable audit log
+//     should move to a generated protobuf message once one is available.
+package wrpbinlog
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+// Direction describes which leg of a WRP exchange an Entry represents.
+type Direction uint8
+
+const (
+	// ClientToServer marks an Entry capturing a message sent from a client to this server.
+	ClientToServer Direction = iota
+
+	// ServerToClient marks an Entry capturing a message sent from this server to a client.
+	ServerToClient
+
+	// Cancel marks an Entry recording that the enclosing call was canceled.
+	Cancel
+
+	// HalfClose marks an Entry recording that one side of the call has finished sending.
+	HalfClose
+
+	// Trailer marks an Entry recording trailing metadata for a completed call.
+	Trailer
+)
+
+// Entry is a single record in the binary log.  It carries enough of a WRP message's
+// headers to reconstruct the shape of an exchange without necessarily retaining the
+// full payload.
+type Entry struct {
+	// Sequence is a monotonically increasing number scoped to a single call.  The
+	// first Entry logged for a call always has Sequence == 1.
+	Sequence uint64
+
+	Direction Direction
+	Timestamp time.Time
+
+	// Peer is the remote address associated with this leg of the call, e.g. the
+	// device id or the HTTP RemoteAddr, depending on where the Entry originated.
+	Peer string
+
+	Source          string
+	Destination     string
+	TransactionUUID string
+
+	// Status is the WRP status code, if any.  A nil value means the originating
+	// message had no status set.
+	Status *int64
+
+	// Payload is the (possibly truncated) WRP payload.  Truncated is set whenever
+	// Payload does not hold the full, original payload.
+	Payload   []byte
+	Truncated bool
+}
+
+// sequencer hands out per-call sequence numbers starting at 1.
+type sequencer struct {
+	n uint64
+}
+
+// next returns the next sequence number for this call.
+func (s *sequencer) next() uint64 {
+	return atomic.AddUint64(&s.n, 1)
+}
+
+// truncate returns at most limit bytes of payload, setting truncated to true if any
+// bytes were dropped.  A payload that fits is returned as is, without copying; a payload
+// that is cut is copied into a new slice.  limit == 0 drops the payload entirely, matching
+// Rule.MaxPayload's documented meaning; a negative limit means no truncation occurs.
+func truncate(payload []byte, limit int) (out []byte, truncated bool) {
+	if limit == 0 {
+		return nil, len(payload) > 0
+	}
+
+	if limit < 0 || len(payload) <= limit {
+		return payload, false
+	}
+
+	out = make([]byte, limit)
+	copy(out, payload)
+	return out, true
+}
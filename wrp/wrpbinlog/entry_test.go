@@ -0,0 +1,93 @@
+package wrpbinlog
+
+import (
+	"context"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+)
+
+func TestSequencerNext(t *testing.T) {
+	var seq sequencer
+	for i, want := range []uint64{1, 2, 3} {
+		if got := seq.next(); got != want {
+			t.Errorf("call %d: expected sequence %d, got %d", i, want, got)
+		}
+	}
+}
+
+func TestTruncate(t *testing.T) {
+	payload := []byte("hello world")
+
+	if out, truncated := truncate(payload, -1); truncated || len(out) != len(payload) {
+		t.Errorf("expected no truncation for a negative limit, got %q truncated=%v", out, truncated)
+	}
+
+	if out, truncated := truncate(payload, len(payload)); truncated || len(out) != len(payload) {
+		t.Errorf("expected no truncation when payload fits exactly, got %q truncated=%v", out, truncated)
+	}
+
+	out, truncated := truncate(payload, 5)
+	if !truncated || string(out) != "hello" {
+		t.Errorf("expected truncation to \"hello\", got %q truncated=%v", out, truncated)
+	}
+}
+
+func TestTruncateZeroLimitDropsPayload(t *testing.T) {
+	if out, truncated := truncate([]byte("hello"), 0); out != nil || !truncated {
+		t.Errorf("expected a zero limit to drop a non-empty payload entirely, got %q truncated=%v", out, truncated)
+	}
+
+	if out, truncated := truncate(nil, 0); out != nil || truncated {
+		t.Errorf("expected a zero limit on an empty payload to not be reported as truncated, got %q truncated=%v", out, truncated)
+	}
+}
+
+func TestEntryForHeaderRule(t *testing.T) {
+	message := &wrp.Message{
+		Source:          "mac:112233445566",
+		Destination:     "dns:some-service",
+		TransactionUUID: "uuid-1",
+	}
+
+	seq := new(sequencer)
+	entry := entryFor(seq, ClientToServer, "peer", message, Rule{Header: true, MaxPayload: -1})
+
+	if entry.Source != message.Source || entry.Destination != message.Destination || entry.TransactionUUID != message.TransactionUUID {
+		t.Errorf("expected headers to be copied onto the entry, got %+v", entry)
+	}
+}
+
+func TestEntryForNoHeaderRule(t *testing.T) {
+	message := &wrp.Message{Source: "mac:112233445566", TransactionUUID: "uuid-1"}
+
+	seq := new(sequencer)
+	entry := entryFor(seq, ClientToServer, "peer", message, Rule{MaxPayload: -1})
+
+	if entry.Source != "" || entry.TransactionUUID != "" {
+		t.Errorf("expected headers to be stripped when Header is false, got %+v", entry)
+	}
+}
+
+func TestCallSequencerCorrelatesAcrossEntries(t *testing.T) {
+	ctx := NewCall(context.Background())
+
+	first := callSequencer(ctx).next()
+	second := callSequencer(ctx).next()
+
+	if first != 1 || second != 2 {
+		t.Errorf("expected sequence 1 then 2 for the same call, got %d then %d", first, second)
+	}
+}
+
+func TestCallSequencerWithoutNewCall(t *testing.T) {
+	ctx := context.Background()
+
+	if got := callSequencer(ctx).next(); got != 1 {
+		t.Errorf("expected a fresh sequencer starting at 1, got %d", got)
+	}
+
+	if got := callSequencer(ctx).next(); got != 1 {
+		t.Errorf("expected each call to callSequencer on a plain context to be independent, got %d", got)
+	}
+}
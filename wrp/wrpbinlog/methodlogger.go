@@ -0,0 +1,185 @@
+package wrpbinlog
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/Comcast/webpa-common/device"
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
+	"github.com/Comcast/webpa-common/wrp/wrphttp"
+	gokithttp "github.com/go-kit/kit/transport/http"
+)
+
+// Logger is the sink for binary log Entry values.  A nil Logger is legal and
+// disables logging entirely; the MethodLogger decorators below treat it as a
+// no-op.
+type Logger interface {
+	Log(entry *Entry)
+}
+
+// MethodLogger decorates the call sites that carry WRP traffic -- device.Router,
+// the wrphttp encoders, and in time wrpendpoint middleware -- so that every
+// message is captured as an Entry without any changes at those call sites.
+type MethodLogger struct {
+	Logger Logger
+	Rules  RuleSet
+}
+
+// rule returns the Rule that applies to destination, and whether logging should
+// occur at all for it.
+func (ml *MethodLogger) rule(destination string) (Rule, bool) {
+	if ml == nil || ml.Logger == nil {
+		return Rule{}, false
+	}
+
+	if len(ml.Rules) == 0 {
+		return Rule{MaxPayload: -1, Header: true}, true
+	}
+
+	return ml.Rules.Match(destination)
+}
+
+func (ml *MethodLogger) log(entry *Entry) {
+	if ml != nil && ml.Logger != nil {
+		ml.Logger.Log(entry)
+	}
+}
+
+// callSequencerKey is the context key under which NewCall stashes a call's sequencer.
+type callSequencerKey struct{}
+
+// NewCall returns a copy of ctx carrying a fresh sequencer, so that every Entry logged while
+// that context (or a descendant of it) is in scope shares one monotonically increasing
+// Sequence.  A caller that drives a full WRP round trip over HTTP -- encoding the outgoing
+// fanout request and, later, encoding the response returned to the original HTTP client --
+// should call NewCall once at the start of the round trip and thread the resulting context
+// through both legs, so DecorateEncodeRequest and DecorateServerEncodeResponseBody correlate
+// their Entry values the same way DecorateRouter already does for a single Route call.
+func NewCall(ctx context.Context) context.Context {
+	return context.WithValue(ctx, callSequencerKey{}, new(sequencer))
+}
+
+// callSequencer returns the sequencer stashed by NewCall, or a fresh one scoped to just this
+// single Entry if ctx was never passed through NewCall.
+func callSequencer(ctx context.Context) *sequencer {
+	if seq, ok := ctx.Value(callSequencerKey{}).(*sequencer); ok {
+		return seq
+	}
+
+	return new(sequencer)
+}
+
+// entryFor builds an Entry for message, truncating and stripping headers as
+// dictated by rule.
+func entryFor(seq *sequencer, direction Direction, peer string, message *wrp.Message, rule Rule) *Entry {
+	entry := &Entry{
+		Sequence:  seq.next(),
+		Direction: direction,
+		Timestamp: time.Now(),
+		Peer:      peer,
+	}
+
+	if rule.Header {
+		entry.Source = message.Source
+		entry.Destination = message.Destination
+		entry.TransactionUUID = message.TransactionUUID
+		entry.Status = message.Status
+	}
+
+	entry.Payload, entry.Truncated = truncate(message.Payload, rule.MaxPayload)
+	return entry
+}
+
+// DecorateRouter wraps next so that every routed request and its response are
+// captured as Entry values.  Each call to Route is treated as a single logical
+// call, with its own monotonically increasing Sequence starting at 1.
+func (ml *MethodLogger) DecorateRouter(next device.Router) device.Router {
+	if ml == nil || ml.Logger == nil {
+		return next
+	}
+
+	return &routerLogger{next: next, ml: ml}
+}
+
+// WrapRouter instruments mh in place, replacing mh.Router with the result of
+// ml.DecorateRouter(mh.Router).  Call this once, after mh.Router has been set to its real
+// device.Router implementation, to have every call routed through mh also captured by ml.  If
+// ml is nil, mh is left unmodified.
+func (ml *MethodLogger) WrapRouter(mh *device.MessageHandler) {
+	if ml != nil {
+		mh.Router = ml.DecorateRouter(mh.Router)
+	}
+}
+
+type routerLogger struct {
+	next device.Router
+	ml   *MethodLogger
+}
+
+func (rl *routerLogger) Route(request *device.Request) (*device.Response, error) {
+	rule, ok := rl.ml.rule(request.Message.To())
+	if !ok {
+		return rl.next.Route(request)
+	}
+
+	seq := new(sequencer)
+	rl.ml.log(entryFor(seq, ClientToServer, request.Message.From(), asMessage(request.Message), rule))
+
+	response, err := rl.next.Route(request)
+	if response != nil {
+		rl.ml.log(entryFor(seq, ServerToClient, request.Message.To(), asMessage(response.Message), rule))
+	} else if err != nil {
+		rl.ml.log(&Entry{Sequence: seq.next(), Direction: Cancel, Timestamp: time.Now()})
+	}
+
+	return response, err
+}
+
+// asMessage adapts a wrp.Routable to the concrete *wrp.Message used for entry
+// headers.  wrp.Message already implements Routable, which is the common case.
+func asMessage(routable wrp.Routable) *wrp.Message {
+	if message, ok := routable.(*wrp.Message); ok {
+		return message
+	}
+
+	return new(wrp.Message)
+}
+
+// DecorateEncodeRequest wraps next so that outgoing fanout requests are captured
+// as ClientToServer entries.
+func (ml *MethodLogger) DecorateEncodeRequest(next gokithttp.EncodeRequestFunc) gokithttp.EncodeRequestFunc {
+	if ml == nil || ml.Logger == nil {
+		return next
+	}
+
+	return func(ctx context.Context, component *http.Request, v interface{}) error {
+		if entity, ok := v.(*wrphttp.Entity); ok {
+			if rule, ok := ml.rule(entity.Message.Destination); ok {
+				ml.log(entryFor(callSequencer(ctx), ClientToServer, component.URL.String(), &entity.Message, rule))
+			}
+		}
+
+		return next(ctx, component, v)
+	}
+}
+
+// DecorateServerEncodeResponseBody wraps next so that responses written back to
+// HTTP clients are captured as ServerToClient entries.
+func (ml *MethodLogger) DecorateServerEncodeResponseBody(next gokithttp.EncodeResponseFunc) gokithttp.EncodeResponseFunc {
+	if ml == nil || ml.Logger == nil {
+		return next
+	}
+
+	return func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
+		if wrpResponse, ok := value.(wrpendpoint.Response); ok {
+			message := wrpResponse.Message()
+			if rule, ok := ml.rule(message.Destination); ok {
+				ml.log(entryFor(callSequencer(ctx), ServerToClient, "", message, rule))
+			}
+		}
+
+		return next(ctx, httpResponse, value)
+	}
+}
@@ -0,0 +1,80 @@
+package wrpbinlog
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/Comcast/webpa-common/wrp"
+	"github.com/Comcast/webpa-common/wrp/wrphttp"
+)
+
+func TestMethodLoggerRuleNoLogger(t *testing.T) {
+	var ml *MethodLogger
+	if _, ok := ml.rule("anything"); ok {
+		t.Error("expected a nil MethodLogger to never log")
+	}
+}
+
+func TestMethodLoggerRuleNoRulesConfigured(t *testing.T) {
+	ml := &MethodLogger{Logger: NewChannelSink(1)}
+
+	rule, ok := ml.rule("mac:112233445566/service/event")
+	if !ok {
+		t.Fatal("expected logging to be enabled with no Rules configured")
+	}
+
+	if !rule.Header || rule.MaxPayload != -1 {
+		t.Errorf("expected full default logging, got %+v", rule)
+	}
+}
+
+func TestDecorateEncodeRequestSharesCallSequencer(t *testing.T) {
+	sink := NewChannelSink(2)
+	ml := &MethodLogger{Logger: sink}
+
+	encode := ml.DecorateEncodeRequest(func(ctx context.Context, component *http.Request, v interface{}) error {
+		return nil
+	})
+
+	ctx := NewCall(context.Background())
+	httpRequest, err := http.NewRequest(http.MethodPost, "http://example.com", nil)
+	if err != nil {
+		t.Fatalf("failed to build request: %s", err)
+	}
+
+	entity := &wrphttp.Entity{Message: wrp.Message{Destination: "dns:some-service"}}
+
+	if err := encode(ctx, httpRequest, entity); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if err := encode(ctx, httpRequest, entity); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	first := <-sink.Entries()
+	second := <-sink.Entries()
+
+	if first.Sequence != 1 || second.Sequence != 2 {
+		t.Errorf("expected correlated sequence numbers 1 and 2, got %d and %d", first.Sequence, second.Sequence)
+	}
+}
+
+func TestDecorateEncodeRequestNilLogger(t *testing.T) {
+	var ml *MethodLogger
+	called := false
+
+	encode := ml.DecorateEncodeRequest(func(ctx context.Context, component *http.Request, v interface{}) error {
+		called = true
+		return nil
+	})
+
+	if err := encode(context.Background(), &http.Request{}, nil); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if !called {
+		t.Error("expected next to be invoked even when logging is disabled")
+	}
+}
@@ -0,0 +1,99 @@
+package wrpbinlog
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Rule describes how much of a matching message to log.  Rules are matched against
+// a WRP destination (e.g. "mac:112233445566/service/event") by prefix, with "*"
+// matching everything.
+type Rule struct {
+	// Pattern is the destination or event prefix this Rule applies to.  "*" matches
+	// any destination.
+	Pattern string
+
+	// MaxPayload is the maximum number of payload bytes to retain.  0 means the
+	// payload is dropped entirely, while a negative value means no limit.
+	MaxPayload int
+
+	// Header, when true, indicates the entry's headers (source, destination,
+	// transaction UUID, status) should be recorded even when the payload is not.
+	Header bool
+}
+
+// RuleSet is an ordered collection of Rules.  The first Rule whose Pattern is a
+// prefix of a destination wins; a RuleSet with no matching Rule means no logging
+// occurs for that destination.
+type RuleSet []Rule
+
+// Match returns the Rule that applies to destination, and true if one was found.
+func (rs RuleSet) Match(destination string) (Rule, bool) {
+	for _, r := range rs {
+		if r.Pattern == "*" || strings.HasPrefix(destination, r.Pattern) {
+			return r, true
+		}
+	}
+
+	return Rule{}, false
+}
+
+// ParseRules parses a grpc binarylog-style configuration string into a RuleSet.
+// Each rule is of the form "pattern={m:maxPayload;h}", separated by commas, e.g.:
+//
+//	*={m:256;h},mac:112233445566={m:0}
+//
+// The "m:" option sets MaxPayload and the bare "h" flag sets Header.  Both options
+// are optional; an empty option list ("pattern=") logs headers only.
+func ParseRules(config string) (RuleSet, error) {
+	config = strings.TrimSpace(config)
+	if config == "" {
+		return nil, nil
+	}
+
+	var rules RuleSet
+	for _, clause := range strings.Split(config, ",") {
+		clause = strings.TrimSpace(clause)
+		if clause == "" {
+			continue
+		}
+
+		eq := strings.IndexByte(clause, '=')
+		if eq < 0 {
+			return nil, fmt.Errorf("wrpbinlog: malformed rule %q: missing '='", clause)
+		}
+
+		rule := Rule{
+			Pattern: clause[:eq],
+		}
+
+		options := strings.TrimSuffix(strings.TrimPrefix(clause[eq+1:], "{"), "}")
+		if options == "" {
+			// An empty option list logs headers only, per the doc comment above.
+			rule.Header = true
+		}
+
+		for _, option := range strings.Split(options, ";") {
+			option = strings.TrimSpace(option)
+			switch {
+			case option == "":
+			case option == "h":
+				rule.Header = true
+			case strings.HasPrefix(option, "m:"):
+				max, err := strconv.Atoi(strings.TrimPrefix(option, "m:"))
+				if err != nil {
+					return nil, fmt.Errorf("wrpbinlog: malformed rule %q: %s", clause, err)
+				}
+
+				rule.MaxPayload = max
+			default:
+				return nil, fmt.Errorf("wrpbinlog: malformed rule %q: unrecognized option %q", clause, option)
+			}
+		}
+
+		rules = append(rules, rule)
+	}
+
+	return rules, nil
+}
@@ -0,0 +1,78 @@
+package wrpbinlog
+
+import "testing"
+
+func TestParseRulesEmptyOptionsLogsHeadersOnly(t *testing.T) {
+	rules, err := ParseRules("mac:112233445566=")
+	if err != nil {
+		t.Fatalf("ParseRules returned error: %s", err)
+	}
+
+	if len(rules) != 1 {
+		t.Fatalf("expected 1 rule, got %d", len(rules))
+	}
+
+	if !rules[0].Header {
+		t.Error("expected Header to default true for an empty option list")
+	}
+
+	if rules[0].MaxPayload != 0 {
+		t.Errorf("expected MaxPayload to default to 0, got %d", rules[0].MaxPayload)
+	}
+}
+
+func TestParseRulesExplicitOptions(t *testing.T) {
+	rules, err := ParseRules("*={m:256;h},mac:112233445566={m:0}")
+	if err != nil {
+		t.Fatalf("ParseRules returned error: %s", err)
+	}
+
+	if len(rules) != 2 {
+		t.Fatalf("expected 2 rules, got %d", len(rules))
+	}
+
+	if rules[0].Pattern != "*" || rules[0].MaxPayload != 256 || !rules[0].Header {
+		t.Errorf("unexpected first rule: %+v", rules[0])
+	}
+
+	if rules[1].Pattern != "mac:112233445566" || rules[1].MaxPayload != 0 || rules[1].Header {
+		t.Errorf("unexpected second rule: %+v", rules[1])
+	}
+}
+
+func TestParseRulesMalformed(t *testing.T) {
+	if _, err := ParseRules("no-equals-sign"); err == nil {
+		t.Error("expected an error for a clause missing '='")
+	}
+
+	if _, err := ParseRules("pattern={m:notanumber}"); err == nil {
+		t.Error("expected an error for a malformed m: option")
+	}
+
+	if _, err := ParseRules("pattern={bogus}"); err == nil {
+		t.Error("expected an error for an unrecognized option")
+	}
+}
+
+func TestRuleSetMatch(t *testing.T) {
+	rules := RuleSet{
+		{Pattern: "mac:112233445566", MaxPayload: 0},
+		{Pattern: "*", MaxPayload: -1, Header: true},
+	}
+
+	if _, ok := rules.Match("mac:112233445566/service/event"); !ok {
+		t.Error("expected a prefix match on the specific rule")
+	}
+
+	rule, ok := rules.Match("mac:aabbccddeeff/service/event")
+	if !ok || rule.Pattern != "*" {
+		t.Error("expected the wildcard rule to match everything else")
+	}
+}
+
+func TestRuleSetMatchNone(t *testing.T) {
+	rules := RuleSet{{Pattern: "mac:112233445566"}}
+	if _, ok := rules.Match("mac:aabbccddeeff/service/event"); ok {
+		t.Error("expected no match for a destination no rule covers")
+	}
+}
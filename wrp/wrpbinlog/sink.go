@@ -0,0 +1,200 @@
+package wrpbinlog
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"encoding/gob"
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Sink is the destination for logged Entry values.  Implementations must be safe
+// for concurrent use, as Log may be called from many goroutines at once.
+type Sink interface {
+	// Log writes entry to the sink.  Errors are not returned, since a Sink sits on
+	// the write path of the traffic it is observing; implementations should
+	// account for their own failures internally (e.g. by logging them).
+	Log(entry *Entry)
+
+	// Close releases any resources held by the Sink, flushing buffered entries
+	// where applicable.
+	Close() error
+}
+
+// encodeFrame gob-encodes entry and prefixes it with its own big-endian uint32
+// length, appending the result to *frame.  gob is used here rather than protobuf: Entry has no
+// generated protobuf type in this module, and gob's self-describing encoding needs no schema or
+// code generation step to stay in sync with Entry's fields.  A dedicated Entry protobuf message
+// would be a reasonable follow-up if this log ever needs to be read outside of Go.
+func encodeFrame(frame *[]byte, entry *Entry) error {
+	var body bytes.Buffer
+	if err := gob.NewEncoder(&body).Encode(entry); err != nil {
+		return err
+	}
+
+	var length [4]byte
+	binary.BigEndian.PutUint32(length[:], uint32(body.Len()))
+
+	*frame = append(*frame, length[:]...)
+	*frame = append(*frame, body.Bytes()...)
+	return nil
+}
+
+// FileSink is a Sink that appends length-prefixed frames to a file, rotating to a
+// new file once the current one reaches MaxBytes.
+type FileSink struct {
+	// Path is the base path to write to.  Rotated files are named Path with a
+	// ".N" suffix appended, where N increases with each rotation.
+	Path string
+
+	// MaxBytes is the size at which the current file is rotated.  A value <= 0
+	// disables rotation.
+	MaxBytes int64
+
+	mutex    sync.Mutex
+	file     *os.File
+	writer   *bufio.Writer
+	written  int64
+	rotation int
+}
+
+// NewFileSink creates a FileSink writing to path, rotating once maxBytes have
+// been written to the current file.
+func NewFileSink(path string, maxBytes int64) (*FileSink, error) {
+	fs := &FileSink{
+		Path:     path,
+		MaxBytes: maxBytes,
+	}
+
+	if err := fs.open(); err != nil {
+		return nil, err
+	}
+
+	return fs, nil
+}
+
+func (fs *FileSink) open() error {
+	file, err := os.OpenFile(fs.Path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0644)
+	if err != nil {
+		return err
+	}
+
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+
+	fs.file = file
+	fs.writer = bufio.NewWriter(file)
+	fs.written = info.Size()
+	return nil
+}
+
+func (fs *FileSink) rotate() error {
+	if err := fs.writer.Flush(); err != nil {
+		return err
+	}
+
+	if err := fs.file.Close(); err != nil {
+		return err
+	}
+
+	fs.rotation++
+	rotated := fmt.Sprintf("%s.%d", fs.Path, fs.rotation)
+	if err := os.Rename(fs.Path, rotated); err != nil {
+		return err
+	}
+
+	return fs.open()
+}
+
+// Log implements Sink.  Failures to write or rotate are silently dropped, since a
+// binary logging Sink must never block or fail the traffic it observes.
+func (fs *FileSink) Log(entry *Entry) {
+	var frame []byte
+	if err := encodeFrame(&frame, entry); err != nil {
+		return
+	}
+
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if fs.MaxBytes > 0 && fs.written+int64(len(frame)) > fs.MaxBytes {
+		if err := fs.rotate(); err != nil {
+			return
+		}
+	}
+
+	n, err := fs.writer.Write(frame)
+	fs.written += int64(n)
+	if err == nil {
+		fs.writer.Flush()
+	}
+}
+
+// Close implements Sink.
+func (fs *FileSink) Close() error {
+	fs.mutex.Lock()
+	defer fs.mutex.Unlock()
+
+	if err := fs.writer.Flush(); err != nil {
+		return err
+	}
+
+	return fs.file.Close()
+}
+
+// ChannelSink is a Sink backed by a buffered channel of Entry values, intended for
+// use in tests that want to assert on logged entries without touching disk.
+type ChannelSink struct {
+	mutex   sync.Mutex
+	closed  bool
+	entries chan *Entry
+}
+
+// NewChannelSink creates a ChannelSink with the given buffer size.
+func NewChannelSink(size int) *ChannelSink {
+	return &ChannelSink{
+		entries: make(chan *Entry, size),
+	}
+}
+
+// Log implements Sink.  If the channel is full, the Entry is dropped rather than
+// blocking the caller.  A Log racing a concurrent Close is safe: once Close has run, Log is a
+// no-op instead of sending on the now-closed channel.
+func (cs *ChannelSink) Log(entry *Entry) {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.closed {
+		return
+	}
+
+	select {
+	case cs.entries <- entry:
+	default:
+	}
+}
+
+// Close implements Sink, closing the underlying channel.  It is safe to call concurrently with
+// Log, and safe to call more than once.
+func (cs *ChannelSink) Close() error {
+	cs.mutex.Lock()
+	defer cs.mutex.Unlock()
+
+	if cs.closed {
+		return nil
+	}
+
+	cs.closed = true
+	close(cs.entries)
+	return nil
+}
+
+// Entries returns the channel of logged Entry values.
+func (cs *ChannelSink) Entries() <-chan *Entry {
+	return cs.entries
+}
@@ -0,0 +1,62 @@
+package wrpbinlog
+
+import (
+	"sync"
+	"testing"
+)
+
+func TestChannelSinkLogAndEntries(t *testing.T) {
+	cs := NewChannelSink(2)
+	cs.Log(&Entry{Sequence: 1})
+	cs.Log(&Entry{Sequence: 2})
+
+	if entry := <-cs.Entries(); entry.Sequence != 1 {
+		t.Errorf("expected sequence 1, got %d", entry.Sequence)
+	}
+
+	if entry := <-cs.Entries(); entry.Sequence != 2 {
+		t.Errorf("expected sequence 2, got %d", entry.Sequence)
+	}
+}
+
+func TestChannelSinkLogDropsWhenFull(t *testing.T) {
+	cs := NewChannelSink(1)
+	cs.Log(&Entry{Sequence: 1})
+	cs.Log(&Entry{Sequence: 2}) // dropped: buffer is full
+
+	if entry := <-cs.Entries(); entry.Sequence != 1 {
+		t.Errorf("expected sequence 1, got %d", entry.Sequence)
+	}
+}
+
+func TestChannelSinkCloseIsIdempotent(t *testing.T) {
+	cs := NewChannelSink(1)
+	if err := cs.Close(); err != nil {
+		t.Errorf("unexpected error: %s", err)
+	}
+
+	if err := cs.Close(); err != nil {
+		t.Errorf("unexpected error on second Close: %s", err)
+	}
+}
+
+func TestChannelSinkConcurrentLogAndClose(t *testing.T) {
+	cs := NewChannelSink(10)
+
+	var wg sync.WaitGroup
+	wg.Add(2)
+
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 100; i++ {
+			cs.Log(&Entry{Sequence: uint64(i)})
+		}
+	}()
+
+	go func() {
+		defer wg.Done()
+		cs.Close()
+	}()
+
+	wg.Wait()
+}
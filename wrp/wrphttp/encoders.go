@@ -5,7 +5,10 @@ import (
 	"context"
 	"io/ioutil"
 	"net/http"
+	"strconv"
+	"time"
 
+	"github.com/Comcast/webpa-common/tracing"
 	"github.com/Comcast/webpa-common/tracing/tracinghttp"
 	"github.com/Comcast/webpa-common/wrp"
 	"github.com/Comcast/webpa-common/wrp/wrpendpoint"
@@ -94,7 +97,9 @@ func ClientEncodeRequestHeaders(custom http.Header) gokithttp.EncodeRequestFunc
 }
 
 // ServerEncodeResponseBody produces a go-kit transport/http.EncodeResponseFunc that transforms a wrphttp.Response into
-// an HTTP response.
+// an HTTP response.  The WRP payload is fully encoded into an in-memory buffer before anything is written to
+// httpResponse, so that an explicit Content-Length can be set and chunked transfer encoding avoided.  Without this,
+// an http.Server.WriteTimeout that fires mid-response truncates the final chunk and silently drops the body.
 func ServerEncodeResponseBody(timeLayout string, format wrp.Format) gokithttp.EncodeResponseFunc {
 	return func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
 		var (
@@ -102,25 +107,102 @@ func ServerEncodeResponseBody(timeLayout string, format wrp.Format) gokithttp.En
 			output      bytes.Buffer
 		)
 
-		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, httpResponse.Header())
-
 		if err := wrpResponse.Encode(&output, format); err != nil {
 			return err
 		}
 
-		httpResponse.Header().Set("Content-Type", format.ContentType())
+		disableGzip(httpResponse)
+
+		header := httpResponse.Header()
+		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, header)
+		header.Set("Content-Type", format.ContentType())
+		header.Set("Content-Length", strconv.Itoa(output.Len()))
+
 		_, err := output.WriteTo(httpResponse)
 		return err
 	}
 }
 
 // ServerEncodeResponseHeaders encodes a WRP response's fields into the HTTP response's headers.  The payload
-// is written as the HTTP response body.
+// is written as the HTTP response body.  As with ServerEncodeResponseBody, the payload is fully buffered before
+// anything is written to httpResponse so that Content-Length can be computed and the response cannot be cut short
+// mid-write.
 func ServerEncodeResponseHeaders(timeLayout string) gokithttp.EncodeResponseFunc {
 	return func(ctx context.Context, httpResponse http.ResponseWriter, value interface{}) error {
-		wrpResponse := value.(wrpendpoint.Response)
-		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, httpResponse.Header())
-		AddMessageHeaders(httpResponse.Header(), wrpResponse.Message())
-		return WriteMessagePayload(httpResponse.Header(), httpResponse, wrpResponse.Message())
+		var (
+			wrpResponse = value.(wrpendpoint.Response)
+			output      bytes.Buffer
+		)
+
+		header := httpResponse.Header()
+		if err := WriteMessagePayload(header, &output, wrpResponse.Message()); err != nil {
+			return err
+		}
+
+		disableGzip(httpResponse)
+
+		tracinghttp.HeadersForSpans(wrpResponse.Spans(), timeLayout, header)
+		AddMessageHeaders(header, wrpResponse.Message())
+		header.Set("Content-Length", strconv.Itoa(output.Len()))
+
+		_, err := output.WriteTo(httpResponse)
+		return err
+	}
+}
+
+// gzipDisabler is implemented by response writers that negotiate gzip content-encoding transparently.  Every path
+// in this file that sets an explicit Content-Length computed from a fully-buffered, uncompressed payload must turn
+// off any such negotiation first, or the advertised length will not match the compressed bytes actually written.
+type gzipDisabler interface {
+	DisableGzip()
+}
+
+// disableGzip turns off gzip negotiation on httpResponse, if it supports doing so.
+func disableGzip(httpResponse http.ResponseWriter) {
+	if disabler, ok := httpResponse.(gzipDisabler); ok {
+		disabler.DisableGzip()
+	}
+}
+
+// ServerErrorEncoder produces a go-kit transport/http.ErrorEncoder that special-cases errors occurring while the
+// request's context is within slack of its deadline.  In that window, rather than letting next write a response that
+// the enclosing http.Server.WriteTimeout may truncate, it emits a fully-buffered WRP message describing a 503
+// timeout fault, with any tracing spans carried by err preserved as headers.  Errors outside that window, or where
+// the context has no deadline, are encoded normally via next.
+func ServerErrorEncoder(timeLayout string, format wrp.Format, slack time.Duration, next gokithttp.ErrorEncoder) gokithttp.ErrorEncoder {
+	return func(ctx context.Context, err error, httpResponse http.ResponseWriter) {
+		deadline, ok := ctx.Deadline()
+		if !ok || time.Until(deadline) > slack {
+			next(ctx, err, httpResponse)
+			return
+		}
+
+		status := int64(http.StatusServiceUnavailable)
+		fault := wrp.Message{
+			Type:    wrp.SimpleRequestResponseMessageType,
+			Status:  &status,
+			Payload: []byte(err.Error()),
+		}
+
+		var output bytes.Buffer
+		if encodeErr := wrp.NewEncoder(&output, format).Encode(&fault); encodeErr != nil {
+			next(ctx, err, httpResponse)
+			return
+		}
+
+		var spans []tracing.Span
+		if spanned, ok := err.(tracing.Spanned); ok {
+			spans = spanned.Spans()
+		}
+
+		disableGzip(httpResponse)
+
+		header := httpResponse.Header()
+		tracinghttp.HeadersForSpans(spans, timeLayout, header)
+		header.Del("Content-Encoding")
+		header.Set("Content-Type", format.ContentType())
+		header.Set("Content-Length", strconv.Itoa(output.Len()))
+		httpResponse.WriteHeader(http.StatusServiceUnavailable)
+		output.WriteTo(httpResponse)
 	}
 }